@@ -0,0 +1,254 @@
+// Package addrbook implements a small on-disk peer address book, loosely
+// modeled on Tendermint p2p's addrbook.go: peers we've merely heard about
+// live in a "new" bucket, and graduate to "old" once we've successfully
+// connected to them. Entries track last-seen time and consecutive failures
+// so stale or unreachable peers can be pruned.
+package addrbook
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Entry describes what the book knows about a single peer.
+type Entry struct {
+	ID             peer.ID   `json:"id"`
+	Addrs          []string  `json:"addrs"`
+	LastSeen       time.Time `json:"last_seen"`
+	FailedAttempts int       `json:"failed_attempts"`
+	Persistent     bool      `json:"persistent"`
+}
+
+// AddrInfo converts an Entry back into a peer.AddrInfo, skipping any
+// addresses that no longer parse.
+func (e *Entry) AddrInfo() peer.AddrInfo {
+	addrs := make([]multiaddr.Multiaddr, 0, len(e.Addrs))
+	for _, s := range e.Addrs {
+		m, err := multiaddr.NewMultiaddr(s)
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, m)
+	}
+	return peer.AddrInfo{ID: e.ID, Addrs: addrs}
+}
+
+// Book is a thread-safe, JSON-backed address book.
+type Book struct {
+	mu   sync.Mutex
+	path string
+
+	New map[string]*Entry `json:"new"`
+	Old map[string]*Entry `json:"old"`
+}
+
+// DefaultPath returns ~/.blackbox/addrbook.json.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".blackbox", "addrbook.json"), nil
+}
+
+// Load reads the book at path, returning an empty book if it doesn't exist yet.
+func Load(path string) (*Book, error) {
+	b := &Book{path: path, New: map[string]*Entry{}, Old: map[string]*Entry{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+	if b.New == nil {
+		b.New = map[string]*Entry{}
+	}
+	if b.Old == nil {
+		b.Old = map[string]*Entry{}
+	}
+	return b, nil
+}
+
+// Save persists the book to disk, creating its parent directory if needed.
+func (b *Book) Save() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(b.path, data, 0600)
+}
+
+// MarkConnected records a successful connection, graduating the peer from
+// the "new" bucket into "old" and resetting its failure count.
+func (b *Book) MarkConnected(id peer.ID, addrs []multiaddr.Multiaddr) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := id.String()
+	delete(b.New, key)
+
+	e, ok := b.Old[key]
+	if !ok {
+		e = &Entry{ID: id}
+		b.Old[key] = e
+	}
+	e.LastSeen = time.Now()
+	e.FailedAttempts = 0
+	if len(addrs) > 0 {
+		e.Addrs = addrStrings(addrs)
+	}
+}
+
+// MarkFailed increments the failure count for a known peer, wherever it lives.
+func (b *Book) MarkFailed(id peer.ID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := id.String()
+	if e, ok := b.Old[key]; ok {
+		e.FailedAttempts++
+		return
+	}
+	if e, ok := b.New[key]; ok {
+		e.FailedAttempts++
+	}
+}
+
+// AddKnown records a peer we've merely heard about (not yet connected to),
+// if it isn't already in the "old" bucket.
+func (b *Book) AddKnown(id peer.ID, addrs []multiaddr.Multiaddr) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := id.String()
+	if _, ok := b.Old[key]; ok {
+		return
+	}
+	e, ok := b.New[key]
+	if !ok {
+		e = &Entry{ID: id}
+		b.New[key] = e
+	}
+	e.LastSeen = time.Now()
+	if len(addrs) > 0 {
+		e.Addrs = addrStrings(addrs)
+	}
+}
+
+// SetPersistent flags (or unflags) a peer so it's reconnected on startup
+// and after disconnects. The peer is created in the "new" bucket if unknown.
+func (b *Book) SetPersistent(id peer.ID, addrs []multiaddr.Multiaddr, persistent bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := id.String()
+	e, ok := b.Old[key]
+	if !ok {
+		e, ok = b.New[key]
+	}
+	if !ok {
+		e = &Entry{ID: id}
+		b.New[key] = e
+	}
+	if len(addrs) > 0 {
+		e.Addrs = addrStrings(addrs)
+	}
+	e.Persistent = persistent
+}
+
+// Remove deletes a peer from both buckets, returning whether it was present.
+func (b *Book) Remove(id peer.ID) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := id.String()
+	_, inNew := b.New[key]
+	_, inOld := b.Old[key]
+	delete(b.New, key)
+	delete(b.Old, key)
+	return inNew || inOld
+}
+
+// Persistent returns the AddrInfos of every peer flagged persistent.
+func (b *Book) Persistent() []peer.AddrInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var out []peer.AddrInfo
+	for _, e := range b.Old {
+		if e.Persistent {
+			out = append(out, e.AddrInfo())
+		}
+	}
+	for _, e := range b.New {
+		if e.Persistent {
+			out = append(out, e.AddrInfo())
+		}
+	}
+	return out
+}
+
+// All returns every known entry (old bucket first, since those are peers
+// we've actually connected to), for listing via /peers.
+func (b *Book) All() []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make([]Entry, 0, len(b.Old)+len(b.New))
+	for _, e := range b.Old {
+		out = append(out, *e)
+	}
+	for _, e := range b.New {
+		out = append(out, *e)
+	}
+	return out
+}
+
+// Prune drops entries last seen longer than maxAge ago, or with more than
+// maxFailures consecutive failed connection attempts, from both buckets.
+// Persistent entries are never dropped. It returns the number of entries
+// dropped.
+func (b *Book) Prune(maxAge time.Duration, maxFailures int) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cutoff := time.Now().Add(-maxAge)
+	dropped := 0
+	for _, bucket := range []map[string]*Entry{b.New, b.Old} {
+		for key, e := range bucket {
+			if e.Persistent {
+				continue
+			}
+			if e.FailedAttempts > maxFailures || (!e.LastSeen.IsZero() && e.LastSeen.Before(cutoff)) {
+				delete(bucket, key)
+				dropped++
+			}
+		}
+	}
+	return dropped
+}
+
+func addrStrings(addrs []multiaddr.Multiaddr) []string {
+	out := make([]string, len(addrs))
+	for i, a := range addrs {
+		out[i] = a.String()
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package dm
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"fmt"
+	"math/big"
+)
+
+// curve25519Prime is 2^255 - 19, the field modulus shared by Ed25519 and
+// Curve25519 — they're birationally equivalent curves over the same field,
+// which is what makes converting one key into the other possible at all.
+var curve25519Prime = func() *big.Int {
+	p := new(big.Int).Lsh(big.NewInt(1), 255)
+	return p.Sub(p, big.NewInt(19))
+}()
+
+// privateKeyToX25519 derives an X25519 private scalar from an Ed25519
+// private key the same way libsodium's crypto_sign_ed25519_sk_to_curve25519
+// does: both schemes clamp the first 32 bytes of SHA-512(seed) identically,
+// so that clamped scalar is directly usable as the X25519 private key.
+func privateKeyToX25519(priv ed25519.PrivateKey) [32]byte {
+	h := sha512.Sum512(priv.Seed())
+	var out [32]byte
+	copy(out[:], h[:32])
+	out[0] &= 248
+	out[31] &= 127
+	out[31] |= 64
+	return out
+}
+
+// publicKeyToX25519 converts an Ed25519 public key — the Edwards curve's
+// y-coordinate, little-endian, with the x sign bit stashed in the top bit —
+// into the corresponding Curve25519 (Montgomery) u-coordinate via the
+// standard birational map u = (1+y)/(1-y) mod p. The sign bit is irrelevant
+// here since X25519 only ever uses the u-coordinate.
+func publicKeyToX25519(pub ed25519.PublicKey) ([32]byte, error) {
+	var out [32]byte
+	if len(pub) != 32 {
+		return out, fmt.Errorf("invalid ed25519 public key length %d", len(pub))
+	}
+
+	buf := make([]byte, 32)
+	copy(buf, pub)
+	buf[31] &= 0x7f
+
+	y := new(big.Int).SetBytes(reverseBytes(buf))
+
+	one := big.NewInt(1)
+	numerator := new(big.Int).Add(one, y)
+	numerator.Mod(numerator, curve25519Prime)
+
+	denominator := new(big.Int).Sub(one, y)
+	denominator.Mod(denominator, curve25519Prime)
+
+	inv := new(big.Int).ModInverse(denominator, curve25519Prime)
+	if inv == nil {
+		return out, fmt.Errorf("public key has no valid curve25519 conversion")
+	}
+
+	u := numerator.Mul(numerator, inv)
+	u.Mod(u, curve25519Prime)
+
+	uBytes := u.Bytes() // big-endian, unpadded
+	for i, b := range uBytes {
+		out[len(uBytes)-1-i] = b
+	}
+	return out, nil
+}
+
+func reverseBytes(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, v := range b {
+		out[len(b)-1-i] = v
+	}
+	return out
+}
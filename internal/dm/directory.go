@@ -0,0 +1,55 @@
+package dm
+
+import (
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Directory maps chosen usernames to peer IDs, populated as peers announce
+// themselves over HelloProtocol when they first meet in the lobby.
+//
+// There is no binding between a username and a peer ID: HelloProtocol isn't
+// authenticated, and the claimed sender name in a DM frame isn't either, so
+// any peer can announce (or sign a message as) a username someone else
+// already claimed. Set keeps whichever mapping it saw last, rather than
+// whichever it saw first, so this isn't trust-on-first-use either: a later
+// conflicting claim silently replaces the earlier one.
+type Directory struct {
+	mu         sync.RWMutex
+	byUsername map[string]peer.ID
+	byPeer     map[peer.ID]string
+}
+
+// NewDirectory returns an empty peer directory.
+func NewDirectory() *Directory {
+	return &Directory{
+		byUsername: map[string]peer.ID{},
+		byPeer:     map[peer.ID]string{},
+	}
+}
+
+// Set records (or updates) the username a peer ID announced. Last writer
+// wins — see the Directory doc comment on the trust implications of that.
+func (d *Directory) Set(username string, id peer.ID) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.byUsername[username] = id
+	d.byPeer[id] = username
+}
+
+// LookupByUsername resolves a username to a peer ID, if known.
+func (d *Directory) LookupByUsername(username string) (peer.ID, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	id, ok := d.byUsername[username]
+	return id, ok
+}
+
+// LookupByPeer resolves a peer ID to the username it announced, if any.
+func (d *Directory) LookupByPeer(id peer.ID) (string, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	name, ok := d.byPeer[id]
+	return name, ok
+}
@@ -0,0 +1,217 @@
+// Package dm implements end-to-end encrypted direct messages between two
+// BlackBox peers, separate from the shared gossipsub lobby. Each message
+// rides over a dedicated libp2p stream protocol, encrypted with an
+// XChaCha20-Poly1305 key derived by X25519 ECDH over the peers' existing
+// Ed25519 node identities — so a DM stays confidential even against a
+// compromised relay, with no extra key exchange or persistence needed.
+//
+// The derived key is static per peer pair, not per session: there's no
+// ephemeral-key handshake, so it doesn't provide forward secrecy (a leaked
+// node identity key compromises every past DM with that peer too). The
+// 192-bit XChaCha20-Poly1305 nonce is large enough that random nonce reuse
+// under that long-lived key is not a practical concern.
+//
+// Usernames are unauthenticated (see Directory) and not bound to a peer
+// ID by anything cryptographic, so /msg by username trusts whichever peer
+// last claimed that name.
+package dm
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+const (
+	// HelloProtocol announces a peer's chosen username so DMs can target
+	// it by name instead of by raw peer ID.
+	HelloProtocol protocol.ID = "/blackbox/hello/1.0.0"
+	// DMProtocol carries one encrypted, length-prefixed DM frame per stream.
+	DMProtocol protocol.ID = "/blackbox/dm/1.0.0"
+
+	maxFrameSize = 64 * 1024
+)
+
+// Message is a decrypted DM delivered to the Manager's onMessage callback.
+type Message struct {
+	From peer.ID
+	// FromUsername is the sender's username as carried in the DM frame
+	// itself, or its peer ID string if the frame carries none.
+	FromUsername string
+	Text         string
+}
+
+// Manager registers the hello/DM stream handlers on a host and lets the
+// caller send and receive encrypted direct messages.
+type Manager struct {
+	h          host.Host
+	username   string
+	x25519Priv [32]byte
+	dir        *Directory
+	onMessage  func(Message)
+}
+
+// NewManager derives this node's X25519 DM key from its existing libp2p
+// (Ed25519) identity and registers the hello/DM stream handlers on h.
+// onMessage is invoked from the protocol's own goroutine for every DM
+// successfully decrypted.
+func NewManager(h host.Host, priv crypto.PrivKey, username string, onMessage func(Message)) (*Manager, error) {
+	raw, err := priv.Raw()
+	if err != nil {
+		return nil, fmt.Errorf("dm: extract raw identity key: %w", err)
+	}
+	if len(raw) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("dm: identity key is not Ed25519 (got %d raw bytes)", len(raw))
+	}
+
+	m := &Manager{
+		h:          h,
+		username:   username,
+		x25519Priv: privateKeyToX25519(ed25519.PrivateKey(raw)),
+		dir:        NewDirectory(),
+		onMessage:  onMessage,
+	}
+
+	h.SetStreamHandler(HelloProtocol, m.handleHello)
+	h.SetStreamHandler(DMProtocol, m.handleDM)
+	return m, nil
+}
+
+// Directory exposes the username → peer ID mappings learned via hello handshakes.
+func (m *Manager) Directory() *Directory { return m.dir }
+
+// Announce sends our username to p over HelloProtocol, so they can /msg us
+// by name. Meant to be called once per newly-met peer (e.g. on connect).
+func (m *Manager) Announce(ctx context.Context, p peer.ID) error {
+	if p == m.h.ID() {
+		return nil
+	}
+	s, err := m.h.NewStream(ctx, p, HelloProtocol)
+	if err != nil {
+		return fmt.Errorf("dm: open hello stream: %w", err)
+	}
+	defer s.Close()
+	return writeFrame(s, []byte(m.username))
+}
+
+func (m *Manager) handleHello(s network.Stream) {
+	defer s.Close()
+	data, err := readFrame(s, maxFrameSize)
+	if err != nil {
+		return
+	}
+	m.dir.Set(string(data), s.Conn().RemotePeer())
+}
+
+// Send resolves target (a peer ID or an announced username), opens a DM
+// stream to it, and sends text as one encrypted frame.
+func (m *Manager) Send(ctx context.Context, target, text string) error {
+	p, err := m.resolve(target)
+	if err != nil {
+		return err
+	}
+	aead, err := m.sessionAEAD(p)
+	if err != nil {
+		return fmt.Errorf("dm: derive session key for %s: %w", p, err)
+	}
+
+	s, err := m.h.NewStream(ctx, p, DMProtocol)
+	if err != nil {
+		return fmt.Errorf("dm: open stream to %s: %w", p, err)
+	}
+	defer s.Close()
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("dm: generate nonce: %w", err)
+	}
+	plaintext := []byte(m.username + "\x00" + text)
+	frame := append(nonce, aead.Seal(nil, nonce, plaintext, nil)...)
+
+	return writeFrame(s, frame)
+}
+
+func (m *Manager) handleDM(s network.Stream) {
+	defer s.Close()
+	from := s.Conn().RemotePeer()
+
+	aead, err := m.sessionAEAD(from)
+	if err != nil {
+		return
+	}
+	frame, err := readFrame(s, maxFrameSize)
+	if err != nil {
+		return
+	}
+	if len(frame) < aead.NonceSize() {
+		return
+	}
+	nonce, ciphertext := frame[:aead.NonceSize()], frame[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return
+	}
+
+	fromUsername := from.String()
+	text := string(plaintext)
+	if senderName, msgText, ok := strings.Cut(string(plaintext), "\x00"); ok {
+		fromUsername, text = senderName, msgText
+		m.dir.Set(senderName, from)
+	}
+
+	if m.onMessage != nil {
+		m.onMessage(Message{From: from, FromUsername: fromUsername, Text: text})
+	}
+}
+
+func (m *Manager) resolve(target string) (peer.ID, error) {
+	if id, err := peer.Decode(target); err == nil {
+		return id, nil
+	}
+	if id, ok := m.dir.LookupByUsername(target); ok {
+		return id, nil
+	}
+	return "", fmt.Errorf("unknown peer or username %q (no hello or DM from them has arrived yet)", target)
+}
+
+// sessionAEAD derives the XChaCha20-Poly1305 key shared with p via X25519
+// ECDH between our X25519 key and p's, both converted from their Ed25519
+// node identities. The key is static for the lifetime of the peer pair (see
+// the package doc comment) — it is not a per-session key.
+func (m *Manager) sessionAEAD(p peer.ID) (cipher.AEAD, error) {
+	pk := m.h.Peerstore().PubKey(p)
+	if pk == nil {
+		return nil, fmt.Errorf("no public key known for peer %s yet (need at least one prior connection or stream)", p)
+	}
+	pub, err := pk.Raw()
+	if err != nil {
+		return nil, fmt.Errorf("no Ed25519 public key known for %s yet: %w", p, err)
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("peer %s identity is not Ed25519", p)
+	}
+
+	peerX25519Pub, err := publicKeyToX25519(ed25519.PublicKey(pub))
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := curve25519.X25519(m.x25519Priv[:], peerX25519Pub[:])
+	if err != nil {
+		return nil, fmt.Errorf("ECDH: %w", err)
+	}
+	key := sha256.Sum256(shared)
+	return chacha20poly1305.NewX(key[:])
+}
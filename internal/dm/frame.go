@@ -0,0 +1,38 @@
+package dm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// writeFrame writes data as a 4-byte big-endian length prefix followed by
+// the data itself, so the reader knows exactly how much to read for one
+// hello announcement or one encrypted DM.
+func writeFrame(w io.Writer, data []byte) error {
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(data)))
+	if _, err := w.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// readFrame reads one length-prefixed frame, rejecting anything claiming to
+// be larger than max so a malicious peer can't force an unbounded allocation.
+func readFrame(r io.Reader, max int) ([]byte, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(lenBuf[:])
+	if int(n) > max {
+		return nil, fmt.Errorf("frame of %d bytes exceeds max %d", n, max)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
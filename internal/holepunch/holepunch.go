@@ -0,0 +1,234 @@
+// Package holepunch implements bridge-assisted NAT hole punching: a
+// lightweight rendezvous server only helps two peers exchange their
+// observed public ip:port candidates, then both sides simultaneously
+// dial every combination until one pair connects. This gives BlackBox
+// nodes a direct P2P link without deploying (or trusting) a full circuit
+// relay.
+package holepunch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// candidateSet is what each side POSTs to, and long-polls from, the bridge.
+type candidateSet struct {
+	LobbyID string   `json:"lobby_id"`
+	IsHost  bool     `json:"is_host"`
+	Addrs   []string `json:"addrs"` // "ip:port" candidates, v4 and v6
+}
+
+const (
+	perAttemptTimeout = 1500 * time.Millisecond
+	pollInterval      = 500 * time.Millisecond
+)
+
+// HolePunch exchanges local/peer candidate ip:port pairs for lobbyID via the
+// bridge at bridgeURL, then simultaneously dials every (local port × peer
+// port) combination and returns the first successful connection. isHost
+// distinguishes the two sides so the bridge can pair them up; ports lists
+// the local source ports to offer as candidates (useful against symmetric
+// NATs, where multiple source ports improve the odds one combination lines
+// up). Callers should fall back to a relay if HolePunch returns an error.
+func HolePunch(ctx context.Context, bridgeURL, lobbyID string, isHost bool, ports []int) (net.Conn, error) {
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("holepunch: no candidate ports supplied")
+	}
+
+	localAddrs, err := localCandidateAddrs(ports)
+	if err != nil {
+		return nil, fmt.Errorf("holepunch: gather local candidates: %w", err)
+	}
+
+	if err := postCandidates(ctx, bridgeURL, candidateSet{
+		LobbyID: lobbyID,
+		IsHost:  isHost,
+		Addrs:   localAddrs,
+	}); err != nil {
+		return nil, fmt.Errorf("holepunch: publish candidates: %w", err)
+	}
+
+	peerSet, err := pollPeerCandidates(ctx, bridgeURL, lobbyID, isHost)
+	if err != nil {
+		return nil, fmt.Errorf("holepunch: fetch peer candidates: %w", err)
+	}
+
+	conn, err := dialAllCombinations(ctx, ports, peerSet.Addrs)
+	if err != nil {
+		return nil, fmt.Errorf("holepunch: %w", err)
+	}
+	return conn, nil
+}
+
+// localCandidateAddrs returns "ip:port" strings for every non-loopback,
+// non-link-local local IPv4/IPv6 address, crossed with every offered source
+// port.
+func localCandidateAddrs(ports []int) ([]string, error) {
+	ifaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	for _, a := range ifaceAddrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		ips = append(ips, ipNet.IP)
+	}
+
+	var out []string
+	for _, ip := range ips {
+		for _, p := range ports {
+			out = append(out, net.JoinHostPort(ip.String(), fmt.Sprintf("%d", p)))
+		}
+	}
+	return out, nil
+}
+
+func postCandidates(ctx context.Context, bridgeURL string, set candidateSet) error {
+	body, err := json.Marshal(set)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, bridgeURL+"/candidates", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bridge returned %s", resp.Status)
+	}
+	return nil
+}
+
+// pollPeerCandidates long-polls the bridge for the other side's candidates
+// under the same lobby ID, until they're available or ctx is done.
+func pollPeerCandidates(ctx context.Context, bridgeURL, lobbyID string, isHost bool) (candidateSet, error) {
+	peerSide := "join"
+	if !isHost {
+		peerSide = "host"
+	}
+	url := fmt.Sprintf("%s/candidates?lobby_id=%s&side=%s", bridgeURL, lobbyID, peerSide)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return candidateSet{}, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			if resp.StatusCode == http.StatusOK {
+				var set candidateSet
+				decodeErr := json.NewDecoder(resp.Body).Decode(&set)
+				resp.Body.Close()
+				if decodeErr == nil && len(set.Addrs) > 0 {
+					return set, nil
+				}
+			} else {
+				resp.Body.Close()
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return candidateSet{}, ctx.Err()
+		}
+	}
+}
+
+// dialResult is one dial's outcome, reported by a dialAllCombinations
+// goroutine over resCh.
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// dialAllCombinations simultaneously dials every (local port × peer address)
+// pair and returns whichever connection completes first. It keeps draining
+// resCh after a winner is found (or ctx is canceled) so any other dials that
+// connect afterward get closed instead of leaked.
+func dialAllCombinations(ctx context.Context, localPorts []int, peerAddrs []string) (net.Conn, error) {
+	attempts := len(localPorts) * len(peerAddrs)
+	if attempts == 0 {
+		return nil, fmt.Errorf("no dial combinations to try")
+	}
+
+	resCh := make(chan dialResult, attempts)
+	dialCtx, cancel := context.WithTimeout(ctx, perAttemptTimeout+2*time.Second)
+	defer cancel()
+
+	dialer := net.Dialer{Timeout: perAttemptTimeout, Control: reusePortControl}
+	for _, lp := range localPorts {
+		localAddr := &net.TCPAddr{Port: lp}
+		for _, peerAddr := range peerAddrs {
+			go func(localAddr *net.TCPAddr, peerAddr string) {
+				d := dialer
+				d.LocalAddr = localAddr
+				conn, err := d.DialContext(dialCtx, "tcp", peerAddr)
+				resCh <- dialResult{conn: conn, err: err}
+			}(localAddr, peerAddr)
+		}
+	}
+
+	var winner net.Conn
+	var firstErr error
+	received := 0
+	for received < attempts {
+		select {
+		case r := <-resCh:
+			received++
+			switch {
+			case r.err != nil:
+				if firstErr == nil {
+					firstErr = r.err
+				}
+			case winner == nil:
+				winner = r.conn
+				cancel() // stop any dials still in flight; we already have a winner
+			default:
+				r.conn.Close() // another combination also connected; don't leak it
+			}
+		case <-ctx.Done():
+			go drainDials(resCh, attempts-received)
+			if winner != nil {
+				return winner, nil
+			}
+			return nil, ctx.Err()
+		}
+	}
+
+	if winner != nil {
+		return winner, nil
+	}
+	if firstErr == nil {
+		firstErr = fmt.Errorf("all %d hole-punch combinations failed", attempts)
+	}
+	return nil, firstErr
+}
+
+// drainDials reads the remaining n in-flight dial results off resCh and
+// closes any that connected, so a caller that returned early (ctx canceled)
+// doesn't leak sockets that complete afterward.
+func drainDials(resCh <-chan dialResult, n int) {
+	for i := 0; i < n; i++ {
+		if r := <-resCh; r.conn != nil {
+			r.conn.Close()
+		}
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package holepunch
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reusePortControl enables SO_REUSEADDR and SO_REUSEPORT on the dial socket
+// so the many concurrent hole-punch attempts in dialAllCombinations can
+// share the same local source port instead of racing for exclusive
+// ownership of it — without this, only one dial per offered port could ever
+// be in flight, defeating the NxM simultaneous-dial strategy entirely.
+// SO_REUSEPORT isn't defined in the standard syscall package on every
+// GOOS/GOARCH pair (e.g. linux/amd64), so this uses x/sys/unix, which
+// libp2p already depends on, for the constant instead.
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	var controlErr error
+	if err := c.Control(func(fd uintptr) {
+		_ = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1)
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1); err != nil {
+			controlErr = err
+		}
+	}); err != nil {
+		return err
+	}
+	return controlErr
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package holepunch
+
+import "syscall"
+
+// reusePortControl is a no-op on Windows: there's no SO_REUSEPORT
+// equivalent, and Windows's SO_REUSEADDR lets an unrelated process silently
+// steal the port rather than just share it, so it isn't safe to set here.
+// Concurrent hole-punch attempts on Windows fall back to one live dial per
+// offered local port.
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,242 @@
+package holepunch
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// ProtocolName is the multiaddr protocol name carrying the lobby ID to
+// hole-punch against, e.g. "/holepunch/my-lobby".
+const ProtocolName = "holepunch"
+
+// protoHolePunch lets a --bridge dial be expressed as an ordinary multiaddr
+// and registered as a regular libp2p Transport, the same way this repo
+// already wires in tcp.NewTCPTransport.
+var protoHolePunch = multiaddr.Protocol{
+	Name:       ProtocolName,
+	Code:       0x7A70, // arbitrary private-use code, unregistered upstream
+	VCode:      multiaddr.CodeToVarint(0x7A70),
+	Size:       multiaddr.LengthPrefixedVarSize,
+	Transcoder: multiaddr.NewTranscoderFromFunctions(lobbyIDToBytes, lobbyIDFromBytes, nil),
+}
+
+func init() {
+	if err := multiaddr.AddProtocol(protoHolePunch); err != nil {
+		panic(err)
+	}
+}
+
+func lobbyIDToBytes(s string) ([]byte, error) { return []byte(s), nil }
+func lobbyIDFromBytes(b []byte) (string, error) {
+	if len(b) == 0 {
+		return "", fmt.Errorf("empty lobby id")
+	}
+	return string(b), nil
+}
+
+// Addr builds the "/holepunch/<lobbyID>" multiaddr used to dial or listen
+// for a given lobby through the configured bridge.
+func Addr(lobbyID string) (multiaddr.Multiaddr, error) {
+	return multiaddr.NewMultiaddr("/" + ProtocolName + "/" + lobbyID)
+}
+
+// Transport implements transport.Transport by hole-punching through a
+// rendezvous bridge instead of dialing/listening on a socket directly.
+type Transport struct {
+	upgrader  transport.Upgrader
+	rcmgr     network.ResourceManager
+	bridgeURL string
+	ports     []int
+}
+
+var _ transport.Transport = (*Transport)(nil)
+
+// NewTransport returns a libp2p transport constructor bound to bridgeURL and
+// the candidate ports to offer, for use with libp2p.Transport(...). Like
+// tcp.NewTCPTransport, it also takes a network.ResourceManager so dials
+// count against the host's resource limits; a nil one (e.g. outside
+// libp2p.New's dependency injection) falls back to no accounting.
+func NewTransport(bridgeURL string, ports []int) func(transport.Upgrader, network.ResourceManager) (*Transport, error) {
+	return func(upgrader transport.Upgrader, rcmgr network.ResourceManager) (*Transport, error) {
+		if rcmgr == nil {
+			rcmgr = &network.NullResourceManager{}
+		}
+		return &Transport{upgrader: upgrader, rcmgr: rcmgr, bridgeURL: bridgeURL, ports: ports}, nil
+	}
+}
+
+// CanDial reports whether addr names a lobby to hole-punch against.
+func (t *Transport) CanDial(addr multiaddr.Multiaddr) bool {
+	_, err := addr.ValueForProtocol(protoHolePunch.Code)
+	return err == nil
+}
+
+// Dial hole-punches to the lobby named in raddr and upgrades the resulting
+// raw connection with the host's configured security + muxer, exactly as
+// the tcp transport does for a normal socket dial.
+func (t *Transport) Dial(ctx context.Context, raddr multiaddr.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	lobbyID, err := raddr.ValueForProtocol(protoHolePunch.Code)
+	if err != nil {
+		return nil, fmt.Errorf("not a holepunch multiaddr: %w", err)
+	}
+
+	connScope, err := t.rcmgr.OpenConnection(network.DirOutbound, true, raddr)
+	if err != nil {
+		return nil, fmt.Errorf("resource manager blocked hole-punch dial: %w", err)
+	}
+
+	conn, err := HolePunch(ctx, t.bridgeURL, lobbyID, false, t.ports)
+	if err != nil {
+		connScope.Done()
+		return nil, fmt.Errorf("hole punch to lobby %q failed: %w", lobbyID, err)
+	}
+
+	maConn, err := manet.WrapNetConn(conn)
+	if err != nil {
+		connScope.Done()
+		conn.Close()
+		return nil, fmt.Errorf("wrap hole-punched conn: %w", err)
+	}
+
+	if err := connScope.SetPeer(p); err != nil {
+		connScope.Done()
+		maConn.Close()
+		return nil, fmt.Errorf("resource manager blocked hole-punch dial to peer %s: %w", p, err)
+	}
+
+	return t.upgrader.Upgrade(ctx, t, maConn, network.DirOutbound, p, connScope)
+}
+
+// Listen accepts inbound hole-punched connections for the lobby named in
+// laddr by repeatedly running the host side of HolePunch in the background.
+// The raw conns are only manet-wrapped; UpgradeListener applies the same
+// security + muxer upgrade that Dial applies on the outbound side, so
+// inbound hole-punched conns end up symmetric with outbound ones.
+func (t *Transport) Listen(laddr multiaddr.Multiaddr) (transport.Listener, error) {
+	lobbyID, err := laddr.ValueForProtocol(protoHolePunch.Code)
+	if err != nil {
+		return nil, fmt.Errorf("not a holepunch multiaddr: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &listener{t: t, lobbyID: lobbyID, laddr: laddr, ctx: ctx, cancel: cancel, conns: make(chan manet.Conn)}
+	go l.run()
+	return t.upgrader.UpgradeListener(t, l), nil
+}
+
+func (t *Transport) Protocols() []int { return []int{protoHolePunch.Code} }
+func (t *Transport) Proxy() bool      { return false }
+
+type listener struct {
+	t       *Transport
+	lobbyID string
+	laddr   multiaddr.Multiaddr
+	ctx     context.Context
+	cancel  context.CancelFunc
+	conns   chan manet.Conn
+}
+
+var _ manet.Listener = (*listener)(nil)
+
+// Accept blocks until another hole punch against this lobby succeeds.
+func (l *listener) Accept() (manet.Conn, error) {
+	select {
+	case c, ok := <-l.conns:
+		if !ok {
+			return nil, fmt.Errorf("holepunch listener closed")
+		}
+		return c, nil
+	case <-l.ctx.Done():
+		return nil, l.ctx.Err()
+	}
+}
+
+func (l *listener) Close() error {
+	l.cancel()
+	return nil
+}
+
+func (l *listener) Multiaddr() multiaddr.Multiaddr { return l.laddr }
+
+func (l *listener) Addr() net.Addr { return multiaddrNetAddr(l.lobbyID) }
+
+// multiaddrNetAddr is a minimal net.Addr for the holepunch "address space" —
+// there's no real local socket address, only a lobby ID.
+type multiaddrNetAddr string
+
+func (a multiaddrNetAddr) Network() string { return protoHolePunch.Name }
+func (a multiaddrNetAddr) String() string  { return string(a) }
+
+const (
+	runBackoffMin = 250 * time.Millisecond
+	runBackoffMax = 30 * time.Second
+)
+
+// sleepOrDone waits for d or ctx cancellation, reporting whether it slept
+// the full duration (mirrors the pubsub resilience loop's helper of the
+// same name in main.go).
+func sleepOrDone(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// nextBackoff doubles cur, capped at ceiling.
+func nextBackoff(cur, ceiling time.Duration) time.Duration {
+	next := cur * 2
+	if next > ceiling {
+		return ceiling
+	}
+	return next
+}
+
+// run repeatedly hole-punches as the host side and feeds successful
+// connections to Accept. Intended to be started once the listener is
+// registered with the swarm. A failed round backs off (capped, reset on the
+// next success) instead of immediately retrying, since a round trips the
+// bridge at least once and an unreachable bridge would otherwise spin this
+// in a tight, bridge-hammering loop.
+func (l *listener) run() {
+	backoff := runBackoffMin
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		default:
+		}
+		conn, err := HolePunch(l.ctx, l.t.bridgeURL, l.lobbyID, true, l.t.ports)
+		if err != nil {
+			if l.ctx.Err() != nil {
+				return
+			}
+			if !sleepOrDone(l.ctx, backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff, runBackoffMax)
+			continue
+		}
+		backoff = runBackoffMin
+
+		maConn, err := manet.WrapNetConn(conn)
+		if err != nil {
+			conn.Close()
+			continue
+		}
+		select {
+		case l.conns <- maConn:
+		case <-l.ctx.Done():
+			maConn.Close()
+			return
+		}
+	}
+}
@@ -47,7 +47,8 @@ func ShowBanner() {
 
 func MainMenu() string {
 	BootSequence() // 👈 plays the startup animation first
-	options := []string{"Join existing host", "Host new lobby"}
+	options := []string{"Join existing host", "Join by lobby name", "Host new lobby"}
+	modes := map[int]string{0: "join", 1: "lobby", 2: "host"}
 	selected := 0
 
 	if err := keyboard.Open(); err != nil {
@@ -71,7 +72,7 @@ func MainMenu() string {
 		}
 
 		fmt.Println("──────────────────────────────────────────")
-		fmt.Println("Use ↑/↓ to navigate, Enter to select, or press 1/2 directly.")
+		fmt.Println("Use ↑/↓ to navigate, Enter to select, or press 1/2/3 directly.")
 
 		char, key, err := keyboard.GetKey()
 		if err != nil {
@@ -88,11 +89,13 @@ func MainMenu() string {
 				selected++
 			}
 		case keyboard.KeyEnter:
-			return map[int]string{0: "join", 1: "host"}[selected]
+			return modes[selected]
 		default:
 			if char == '1' {
 				return "join"
 			} else if char == '2' {
+				return "lobby"
+			} else if char == '3' {
 				return "host"
 			}
 		}
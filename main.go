@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -12,18 +13,29 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"io/ioutil"
 
+	"github.com/HackNoGood/BlackBox/internal/addrbook"
+	"github.com/HackNoGood/BlackBox/internal/dm"
+	"github.com/HackNoGood/BlackBox/internal/holepunch"
 	"github.com/HackNoGood/BlackBox/internal/ui"
 	libp2p "github.com/libp2p/go-libp2p"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	drouting "github.com/libp2p/go-libp2p/p2p/discovery/routing"
+	dutil "github.com/libp2p/go-libp2p/p2p/discovery/util"
+	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
 	noise "github.com/libp2p/go-libp2p/p2p/security/noise"
 	tcp "github.com/libp2p/go-libp2p/p2p/transport/tcp"
 	"github.com/multiformats/go-multiaddr"
@@ -32,6 +44,14 @@ import (
 const (
 	topicName = "blackbox/lobby"
 
+	// rendezvousPrefix namespaces lobby names so BlackBox's DHT advertisements
+	// don't collide with other applications sharing the same DHT.
+	rendezvousPrefix = "blackbox/lobby/"
+
+	// relayNamespace is the DHT rendezvous string BlackBox relays advertise
+	// themselves under so NATed clients can find one without a static list.
+	relayNamespace = "/libp2p/relay"
+
 	ansiReset  = "\x1b[0m"
 	ansiGreen  = "\x1b[92m"
 	ansiCyan   = "\x1b[96m"
@@ -40,6 +60,15 @@ const (
 	ansiDim    = "\x1b[2m"
 )
 
+// defaultBootstrapPeers are the public IPFS bootstrap nodes, used to join the
+// DHT when the user hasn't configured their own via --bootstrap.
+var defaultBootstrapPeers = []string{
+	"/dnsaddr/bootstrap.libp2p.io/p2p/QmNnooDu7bfjPFoTZYxMNLWUQJyrVwtbZg5gBMjTezGAJN",
+	"/dnsaddr/bootstrap.libp2p.io/p2p/QmQCU2EcMqAqQPR2i9bChDtGNJchTbq5TbXJJ16u19uLTa",
+	"/dnsaddr/bootstrap.libp2p.io/p2p/QmbLHAnMoJPWSCR5Zhtx6BHJX9KiKNN6tpvbUcqanj75Nb",
+	"/dnsaddr/bootstrap.libp2p.io/p2p/QmcZf59bWwK5XFi76CZX8cbJ4BhTzzA3gU1ZjYZcYW3dwt",
+}
+
 // Ensure checks if a key file exists; if not, it generates and saves one.
 func Ensure(path string) (crypto.PrivKey, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -118,22 +147,584 @@ func parseRelayInfos(csv string) ([]peer.AddrInfo, error) {
 	return infos, nil
 }
 
+// rendezvousString namespaces a user-chosen lobby name for DHT advertising.
+func rendezvousString(lobbyName string) string {
+	return rendezvousPrefix + lobbyName
+}
+
+// parseBootstrapInfos parses a comma-separated list of bootstrap multiaddrs
+// into AddrInfos, same convention as parseRelayInfos.
+func parseBootstrapInfos(csv string) ([]peer.AddrInfo, error) {
+	if strings.TrimSpace(csv) == "" {
+		return nil, nil
+	}
+	parts := strings.Split(csv, ",")
+	infos := make([]peer.AddrInfo, 0, len(parts))
+	for _, p := range parts {
+		s := strings.TrimSpace(p)
+		if s == "" {
+			continue
+		}
+		maddr, err := multiaddr.NewMultiaddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad bootstrap multiaddr %q: %w", s, err)
+		}
+		ai, err := peer.AddrInfoFromP2pAddr(maddr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse bootstrap AddrInfo %q: %w", s, err)
+		}
+		infos = append(infos, *ai)
+	}
+	return infos, nil
+}
+
+// setupDHT creates a Kademlia DHT in auto (client/server) mode, bootstraps
+// its routing table, and connects to the given bootstrap peers concurrently.
+func setupDHT(ctx context.Context, h host.Host, bootstrapPeers []peer.AddrInfo) (*dht.IpfsDHT, error) {
+	kadDHT, err := dht.New(ctx, h, dht.Mode(dht.ModeAuto))
+	if err != nil {
+		return nil, fmt.Errorf("create dht: %w", err)
+	}
+	if err := kadDHT.Bootstrap(ctx); err != nil {
+		return nil, fmt.Errorf("bootstrap dht: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, pi := range bootstrapPeers {
+		wg.Add(1)
+		go func(pi peer.AddrInfo) {
+			defer wg.Done()
+			if err := h.Connect(ctx, pi); err != nil {
+				log.Printf("[dht] bootstrap connect to %s failed: %v", pi.ID, err)
+			}
+		}(pi)
+	}
+	wg.Wait()
+
+	return kadDHT, nil
+}
+
+// joinLobby looks up peers advertising under lobbyName via the DHT and dials
+// each one found, returning an error if none could be reached.
+func joinLobby(ctx context.Context, h host.Host, routingDiscovery *drouting.RoutingDiscovery, lobbyName string) error {
+	fmt.Printf("[DHT] Searching for peers in lobby %q...\n", lobbyName)
+	peerChan, err := routingDiscovery.FindPeers(ctx, rendezvousString(lobbyName))
+	if err != nil {
+		return fmt.Errorf("find peers: %w", err)
+	}
+
+	connected := 0
+	for pi := range peerChan {
+		if pi.ID == h.ID() || len(pi.Addrs) == 0 {
+			continue
+		}
+		if h.Network().Connectedness(pi.ID) == network.Connected {
+			continue
+		}
+		if err := h.Connect(ctx, pi); err != nil {
+			log.Printf("[DHT] connect to %s failed: %v", pi.ID, err)
+			continue
+		}
+		fmt.Printf("[DHT] Connected to peer %s\n", pi.ID)
+		connected++
+	}
+	if connected == 0 {
+		return fmt.Errorf("no reachable peers found for lobby %q", lobbyName)
+	}
+	return nil
+}
+
+// buildRelayResources turns the --relay-* flags into circuit-v2 relay
+// resource limits, starting from the library defaults. relayv2.Resources has
+// no single knob for "total memory" or separate inbound/outbound stream
+// caps, so these map onto the limits the library actually enforces:
+// maxReservations bounds how many peers can hold a reservation on this
+// relay at once, maxCircuitsPerPeer bounds concurrent relayed connections
+// for any one of them, and connDataLimitMB resets a relayed connection once
+// it has forwarded that much data in either direction.
+func buildRelayResources(maxReservations, maxCircuitsPerPeer, connDataLimitMB int, reservationTTL time.Duration) relayv2.Resources {
+	res := relayv2.DefaultResources()
+	res.ReservationTTL = reservationTTL
+	res.MaxReservations = maxReservations
+	res.MaxCircuits = maxCircuitsPerPeer
+	res.Limit = &relayv2.RelayLimit{
+		Duration: reservationTTL,
+		Data:     int64(connDataLimitMB) * 1024 * 1024,
+	}
+	return res
+}
+
+// detectReachability waits up to timeout for AutoNAT's first public or
+// private reachability verdict, instead of blocking forever. Unknown verdicts
+// are skipped; ReachabilityUnknown is returned only when none arrives in time.
+func detectReachability(h host.Host, timeout time.Duration) network.Reachability {
+	sub, err := h.EventBus().Subscribe(new(event.EvtLocalReachabilityChanged))
+	if err != nil {
+		return network.ReachabilityUnknown
+	}
+	defer sub.Close()
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case evt := <-sub.Out():
+			if e, ok := evt.(event.EvtLocalReachabilityChanged); ok && e.Reachability != network.ReachabilityUnknown {
+				return e.Reachability
+			}
+		case <-deadline:
+			return network.ReachabilityUnknown
+		}
+	}
+}
+
+// relayPeerSource returns a libp2p AutoRelay peer source backed by the DHT:
+// it looks up peers advertising under relayNamespace on demand instead of
+// requiring a static --relays list.
+func relayPeerSource(kadDHT *dht.IpfsDHT) func(ctx context.Context, num int) <-chan peer.AddrInfo {
+	return func(ctx context.Context, num int) <-chan peer.AddrInfo {
+		out := make(chan peer.AddrInfo)
+		go func() {
+			defer close(out)
+			rd := drouting.NewRoutingDiscovery(kadDHT)
+			peerChan, err := rd.FindPeers(ctx, relayNamespace)
+			if err != nil {
+				log.Printf("[relay] discovery failed: %v", err)
+				return
+			}
+			sent := 0
+			for pi := range peerChan {
+				if sent >= num {
+					return
+				}
+				select {
+				case out <- pi:
+					sent++
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// watchAddrBook registers a libp2p Notifiee that records every peer we
+// successfully connect to in the address book. It does not touch
+// FailedAttempts on disconnect: a disconnect (clean exit, peer going idle)
+// isn't a failed connection attempt, and counting it that way would
+// eventually prune peers we've actually talked to successfully. Failures
+// are recorded only where an actual connect attempt errors out, in
+// reconnectPersistent and redialKnownPeers.
+//
+// Only outbound connections update the stored address: RemoteMultiaddr() on
+// an inbound connection is the peer's ephemeral NAT source port, not a
+// dialable listen address, and overwriting a persistent peer's real address
+// with it would leave reconnectPersistent/redialKnownPeers dialing a dead
+// port the next time that peer happens to dial in first.
+func watchAddrBook(h host.Host, book *addrbook.Book) {
+	h.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(n network.Network, c network.Conn) {
+			var addrs []multiaddr.Multiaddr
+			if c.Stat().Direction == network.DirOutbound {
+				addrs = []multiaddr.Multiaddr{c.RemoteMultiaddr()}
+			}
+			book.MarkConnected(c.RemotePeer(), addrs)
+		},
+	})
+}
+
+// announceDM registers a libp2p Notifiee that announces our username to
+// every newly connected peer over dm's HelloProtocol, so /msg can target
+// them by name instead of by raw peer ID as soon as they show up.
+func announceDM(ctx context.Context, h host.Host, dmManager *dm.Manager) {
+	h.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(n network.Network, c network.Conn) {
+			go func() {
+				if err := dmManager.Announce(ctx, c.RemotePeer()); err != nil {
+					log.Printf("[dm] announce to %s failed: %v", c.RemotePeer(), err)
+				}
+			}()
+		},
+	})
+}
+
+// reconnectPersistent attempts, in the background and with a capped
+// exponential backoff per peer, to keep every persistent peer connected.
+// It keeps retrying for the lifetime of ctx. It covers the peers flagged
+// persistent when it is called; peers flagged later are not picked up.
+func reconnectPersistent(ctx context.Context, h host.Host, book *addrbook.Book) {
+	for _, info := range book.Persistent() {
+		go func(info peer.AddrInfo) {
+			delay := 1 * time.Second
+			const maxDelay = 2 * time.Minute
+			for {
+				if ctx.Err() != nil {
+					return
+				}
+				if h.Network().Connectedness(info.ID) == network.Connected {
+					delay = 1 * time.Second
+				} else if err := h.Connect(ctx, info); err != nil {
+					book.MarkFailed(info.ID)
+					log.Printf("[addrbook] reconnect to persistent peer %s failed (retrying in %s): %v", info.ID, delay, err)
+					select {
+					case <-time.After(delay):
+					case <-ctx.Done():
+						return
+					}
+					if delay *= 2; delay > maxDelay {
+						delay = maxDelay
+					}
+					continue
+				}
+				select {
+				case <-time.After(30 * time.Second):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(info)
+	}
+}
+
+// runAddrBookPruner periodically drops stale or repeatedly-unreachable
+// entries (never persistent ones) and saves the book, until ctx is done.
+func runAddrBookPruner(ctx context.Context, book *addrbook.Book, maxAge time.Duration, maxFailures int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if n := book.Prune(maxAge, maxFailures); n > 0 {
+				log.Printf("[addrbook] pruned %d stale peer(s)", n)
+			}
+			if err := book.Save(); err != nil {
+				log.Printf("[addrbook] save failed: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handlePeerCommand implements the /peers, /addpeer and /removepeer chat
+// commands against the address book.
+func handlePeerCommand(ctx context.Context, h host.Host, book *addrbook.Book, line string) {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case "/peers":
+		entries := book.All()
+		if len(entries) == 0 {
+			fmt.Println("[addrbook] no known peers yet")
+			return
+		}
+		for _, e := range entries {
+			tag := ""
+			if e.Persistent {
+				tag = " (persistent)"
+			}
+			fmt.Printf("  %s%s — last seen %s, %d failed attempt(s)\n", e.ID, tag, e.LastSeen.Format(time.RFC3339), e.FailedAttempts)
+		}
+	case "/addpeer":
+		if len(fields) < 2 {
+			fmt.Println("usage: /addpeer <multiaddr>")
+			return
+		}
+		maddr, err := multiaddr.NewMultiaddr(fields[1])
+		if err != nil {
+			fmt.Printf("[addrbook] invalid multiaddr: %v\n", err)
+			return
+		}
+		info, err := peer.AddrInfoFromP2pAddr(maddr)
+		if err != nil {
+			fmt.Printf("[addrbook] invalid peer address: %v\n", err)
+			return
+		}
+		book.SetPersistent(info.ID, info.Addrs, true)
+		if err := book.Save(); err != nil {
+			log.Printf("[addrbook] save failed: %v", err)
+		}
+		if err := h.Connect(ctx, *info); err != nil {
+			fmt.Printf("[addrbook] added %s, but connect failed: %v\n", info.ID, err)
+			return
+		}
+		fmt.Printf("[addrbook] added and connected to %s\n", info.ID)
+	case "/removepeer":
+		if len(fields) < 2 {
+			fmt.Println("usage: /removepeer <peerID>")
+			return
+		}
+		id, err := peer.Decode(fields[1])
+		if err != nil {
+			fmt.Printf("[addrbook] invalid peer ID: %v\n", err)
+			return
+		}
+		if book.Remove(id) {
+			_ = book.Save()
+			fmt.Printf("[addrbook] removed %s\n", id)
+		} else {
+			fmt.Printf("[addrbook] %s was not in the address book\n", id)
+		}
+	}
+}
+
+// handleDMCommand implements the /msg chat command, sending a single
+// end-to-end encrypted direct message to a peer ID or announced username.
+func handleDMCommand(ctx context.Context, dmManager *dm.Manager, line string) {
+	rest := strings.TrimPrefix(line, "/msg ")
+	target, text, ok := strings.Cut(rest, " ")
+	if !ok || target == "" || text == "" {
+		fmt.Println("usage: /msg <peerID|username> <message>")
+		return
+	}
+	if err := dmManager.Send(ctx, target, text); err != nil {
+		fmt.Printf("[dm] send failed: %v\n", err)
+		return
+	}
+	fmt.Printf("%s[DM to %s]%s %s\n", ansiYellow, target, ansiReset, text)
+}
+
+// topicHandle holds the currently-joined pubsub topic so the publishing
+// side (the scanner loop) always sees the latest one across reconnects,
+// without the receive loop and the publisher racing on a shared variable.
+type topicHandle struct {
+	mu    sync.RWMutex
+	topic *pubsub.Topic
+}
+
+func (h *topicHandle) set(t *pubsub.Topic) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.topic = t
+}
+
+func (h *topicHandle) Publish(ctx context.Context, data []byte) error {
+	h.mu.RLock()
+	t := h.topic
+	h.mu.RUnlock()
+	if t == nil {
+		return fmt.Errorf("not subscribed to %q yet", topicName)
+	}
+	return t.Publish(ctx, data)
+}
+
+// dhtHandle holds the DHT instance used for relay peer discovery, set once
+// after libp2p.New returns and read from AutoRelay's peer-source goroutine —
+// same race this guards against as topicHandle, just for the DHT instead of
+// the pubsub topic.
+type dhtHandle struct {
+	mu  sync.RWMutex
+	dht *dht.IpfsDHT
+}
+
+func (h *dhtHandle) set(d *dht.IpfsDHT) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dht = d
+}
+
+func (h *dhtHandle) get() *dht.IpfsDHT {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.dht
+}
+
+// sleepOrDone waits for d or ctx cancellation, reporting whether it slept
+// the full duration (false means the caller should stop immediately).
+func sleepOrDone(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// nextBackoff doubles cur, capped at ceiling.
+func nextBackoff(cur, ceiling time.Duration) time.Duration {
+	next := cur * 2
+	if next > ceiling {
+		return ceiling
+	}
+	return next
+}
+
+// backoffCeiling picks a shorter cap for errors that look like transient
+// network hiccups (temporary net.Errors), and a longer one otherwise —
+// e.g. a relay/host actually going away for good.
+func backoffCeiling(err error) time.Duration {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Temporary() {
+		return 5 * time.Second
+	}
+	return 5 * time.Minute
+}
+
+// redialKnownPeers makes a single, best-effort reconnect attempt against
+// every persistent address-book peer and bootstrap peer we aren't already
+// connected to. It's called whenever the pubsub loop has to rejoin, since
+// a dropped topic often means the underlying connections dropped too.
+func redialKnownPeers(ctx context.Context, h host.Host, book *addrbook.Book, bootstrapPeers []peer.AddrInfo) {
+	targets := append(book.Persistent(), bootstrapPeers...)
+	for _, info := range targets {
+		if h.Network().Connectedness(info.ID) == network.Connected {
+			continue
+		}
+		go func(info peer.AddrInfo) {
+			if err := h.Connect(ctx, info); err != nil {
+				book.MarkFailed(info.ID)
+				log.Printf("[pubsub] redial to %s failed: %v", info.ID, err)
+			}
+		}(info)
+	}
+}
+
+// watchPeerLoss returns a channel that gets a signal every time the last
+// remaining connection drops (e.g. a Wi-Fi flap). Gossipsub's subscription
+// doesn't itself error out when every peer disappears — it just stops
+// delivering — so receiveMessages also selects on this channel to notice
+// and force a rejoin instead of silently sitting on a dead topic.
+func watchPeerLoss(h host.Host) <-chan struct{} {
+	lost := make(chan struct{}, 1)
+	h.Network().Notify(&network.NotifyBundle{
+		DisconnectedF: func(n network.Network, c network.Conn) {
+			if len(n.Peers()) > 0 {
+				return
+			}
+			select {
+			case lost <- struct{}{}:
+			default:
+			}
+		},
+	})
+	return lost
+}
+
+// receiveMessages prints incoming messages until sub.Next errors out, ctx is
+// done, or lostAllPeers fires, resetting *backoff to minBackoff after every
+// successfully received message so a long-lived, healthy session doesn't
+// carry a stale delay into its next hiccup.
+func receiveMessages(ctx context.Context, sub *pubsub.Subscription, h host.Host, backoff *time.Duration, minBackoff time.Duration, lostAllPeers <-chan struct{}) error {
+	type next struct {
+		msg *pubsub.Message
+		err error
+	}
+	nextCh := make(chan next, 1)
+	go func() {
+		for {
+			msg, err := sub.Next(ctx)
+			nextCh <- next{msg, err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case n := <-nextCh:
+			if n.err != nil {
+				return n.err
+			}
+			*backoff = minBackoff
+
+			if n.msg.ReceivedFrom == h.ID() {
+				continue
+			}
+			msgText := string(n.msg.Data)
+			parts := strings.SplitN(msgText, "]:", 2)
+			if len(parts) == 2 && strings.HasPrefix(parts[0], "[") {
+				username := strings.TrimPrefix(parts[0], "[")
+				message := parts[1]
+				fmt.Printf("\n%s%s%s: %s\n> ", ansiYellow, username, ansiReset, message)
+			} else {
+				fmt.Printf("\n%s\n> ", msgText)
+			}
+		case <-lostAllPeers:
+			return fmt.Errorf("lost connection to every peer")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// runResilientPubSub keeps the lobby topic alive for as long as ctx lives:
+// on any error from sub.Next — a dropped topic, a disconnected host, a
+// transient network error — or on losing every connected peer, even if
+// sub.Next itself stays quiet about it (e.g. a Wi-Fi flap), it rejoins the
+// topic, resubscribes, redials known peers, and keeps going with a capped
+// exponential backoff between attempts, logging the delay at each retry.
+func runResilientPubSub(ctx context.Context, ps *pubsub.PubSub, h host.Host, handle *topicHandle, book *addrbook.Book, bootstrapPeers []peer.AddrInfo) {
+	const minBackoff = 5 * time.Millisecond
+	backoff := minBackoff
+	lostAllPeers := watchPeerLoss(h)
+
+	for ctx.Err() == nil {
+		t, err := ps.Join(topicName)
+		if err != nil {
+			log.Printf("[pubsub] join %q failed, retrying in %s: %v", topicName, backoff, err)
+			if !sleepOrDone(ctx, backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff, backoffCeiling(err))
+			continue
+		}
+
+		sub, err := t.Subscribe()
+		if err != nil {
+			log.Printf("[pubsub] subscribe to %q failed, retrying in %s: %v", topicName, backoff, err)
+			_ = t.Close()
+			if !sleepOrDone(ctx, backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff, backoffCeiling(err))
+			continue
+		}
+
+		handle.set(t)
+		redialKnownPeers(ctx, h, book, bootstrapPeers)
+
+		err = receiveMessages(ctx, sub, h, &backoff, minBackoff, lostAllPeers)
+
+		sub.Cancel()
+		_ = t.Close()
+		handle.set(nil)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("[pubsub] lost connection to %q (%v); rejoining in %s", topicName, err, backoff)
+		if !sleepOrDone(ctx, backoff) {
+			return
+		}
+		backoff = nextBackoff(backoff, backoffCeiling(err))
+	}
+}
+
 func main() {
 	// 🟢 Boot animation + menu selection
 	mode := ui.MainMenu()
 
 	var (
-		joinAddr string
-		username string
-		hostMode bool
+		joinAddr  string
+		username  string
+		hostMode  bool
+		lobbyMode bool
+		lobbyName string
 	)
 
 	switch mode {
 	case "host":
 		hostMode = true
+		fmt.Print("Enter a lobby name (optional, lets others join by name): ")
+		fmt.Scanln(&lobbyName)
 	case "join":
-		fmt.Print("Enter connection address: ")
+		fmt.Print("Enter connection address (multiaddr, or <lobbyID>@<peerID> with --bridge): ")
 		fmt.Scanln(&joinAddr)
+	case "lobby":
+		lobbyMode = true
+		fmt.Print("Enter the lobby name to join: ")
+		fmt.Scanln(&lobbyName)
 	default:
 		fmt.Println("Invalid selection. Exiting.")
 		return
@@ -145,8 +736,27 @@ func main() {
 	// flags
 	port := flag.Int("port", 4001, "Listen port for libp2p (use a different port to run multiple local instances)")
 	relaysCSV := flag.String("relays", "", "Comma-separated relay multiaddrs (to enable AutoRelay)")
+	bootstrapCSV := flag.String("bootstrap", strings.Join(defaultBootstrapPeers, ","), "Comma-separated DHT bootstrap multiaddrs (used for lobby-name and relay discovery)")
+	relayService := flag.Bool("relay-service", false, "Run this node as a circuit-v2 relay for other BlackBox users")
+	relayMaxReservations := flag.Int("relay-max-reservations", 128, "Max peers that may hold a relay reservation on this node at once (relay-service mode)")
+	relayMaxCircuitsPerPeer := flag.Int("relay-max-circuits-per-peer", 16, "Max concurrent relayed connections per peer (relay-service mode)")
+	relayConnDataLimitMB := flag.Int("relay-conn-data-limit-mb", 128, "Reset a relayed connection once it has forwarded this much data (MB) in either direction (relay-service mode)")
+	relayReservationTTL := flag.Duration("relay-reservation-ttl", time.Hour, "How long a relay reservation stays valid (relay-service mode)")
+	addrbookMaxAge := flag.Duration("addrbook-max-age", 30*24*time.Hour, "Drop address book entries not seen within this long")
+	addrbookMaxFailures := flag.Int("addrbook-max-failures", 10, "Drop address book entries with more than this many consecutive failed connects")
+	bridgeURL := flag.String("bridge", "", "Rendezvous/bridge server URL for NAT hole punching (direct P2P without a relay)")
+	holepunchPortsCSV := flag.String("holepunch-ports", "45000,45001,45002,45003", "Comma-separated local candidate ports to offer for NAT hole punching")
 	flag.Parse()
 
+	var holepunchPorts []int
+	if *bridgeURL != "" {
+		ports, err := parsePorts(*holepunchPortsCSV)
+		if err != nil {
+			log.Fatalf("parse --holepunch-ports: %v", err)
+		}
+		holepunchPorts = ports
+	}
+
 	// Identity setup
 	home, _ := os.UserHomeDir()
 	dataDir := filepath.Join(home, ".blackbox")
@@ -166,6 +776,9 @@ func main() {
 		fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", *port),
 		fmt.Sprintf("/ip6/::/tcp/%d", *port),
 	}
+	if hostMode && lobbyName != "" && *bridgeURL != "" {
+		listenAddrs = append(listenAddrs, "/"+holepunch.ProtocolName+"/"+lobbyName)
+	}
 
 	// Build libp2p options
 	opts := []libp2p.Option{
@@ -177,8 +790,27 @@ func main() {
 		libp2p.NATPortMap(),
 	}
 
-	// If user supplied relays, enable AutoRelay using those static relays
-	if *relaysCSV != "" {
+	if *bridgeURL != "" {
+		opts = append(opts, libp2p.Transport(holepunch.NewTransport(*bridgeURL, holepunchPorts)))
+	}
+
+	// needsDHT is true whenever something downstream depends on the Kademlia
+	// DHT: lobby-name discovery, advertising as a relay, or discovering one.
+	needsDHT := lobbyName != "" || *relayService || *relaysCSV == ""
+
+	// relayDHT is filled in once the host exists (relayPeerSource is only
+	// invoked lazily by AutoRelay, after libp2p.New returns). It's read from
+	// AutoRelay's own goroutine, so it's guarded by dhtHandle rather than a
+	// bare variable.
+	relayDHT := &dhtHandle{}
+
+	switch {
+	case *relayService:
+		opts = append(opts, libp2p.EnableRelayService(
+			relayv2.WithResources(buildRelayResources(*relayMaxReservations, *relayMaxCircuitsPerPeer, *relayConnDataLimitMB, *relayReservationTTL)),
+		))
+		fmt.Println("[Relay] Running as a circuit-v2 relay for other BlackBox nodes")
+	case *relaysCSV != "":
 		staticRelays, err := parseRelayInfos(*relaysCSV)
 		if err != nil {
 			log.Fatalf("parse --relays: %v", err)
@@ -192,8 +824,20 @@ func main() {
 			)
 			fmt.Println("[AutoRelay] Using static relays from --relays")
 		}
-	} else {
-		fmt.Println("[AutoRelay] No relays provided. Running without AutoRelay (no panic).")
+	default:
+		opts = append(opts,
+			libp2p.EnableAutoRelayWithPeerSource(func(ctx context.Context, num int) <-chan peer.AddrInfo {
+				d := relayDHT.get()
+				if d == nil {
+					out := make(chan peer.AddrInfo)
+					close(out)
+					return out
+				}
+				return relayPeerSource(d)(ctx, num)
+			}),
+			libp2p.EnableHolePunching(),
+		)
+		fmt.Println("[AutoRelay] No relays provided. Discovering relays via AutoNAT + DHT as needed.")
 	}
 
 	h, err := libp2p.New(opts...)
@@ -202,21 +846,67 @@ func main() {
 	}
 	defer h.Close()
 
-	ps, err := pubsub.NewGossipSub(ctx, h)
+	abPath, err := addrbook.DefaultPath()
 	if err != nil {
 		log.Fatal(err)
 	}
-	t, err := ps.Join(topicName)
+	book, err := addrbook.Load(abPath)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("load addrbook: %v", err)
+	}
+	watchAddrBook(h, book)
+	reconnectPersistent(ctx, h, book)
+	go runAddrBookPruner(ctx, book, *addrbookMaxAge, *addrbookMaxFailures, 1*time.Hour)
+
+	dmManager, err := dm.NewManager(h, priv, username, func(msg dm.Message) {
+		fmt.Printf("\n%s[DM from %s]%s %s\n> ", ansiYellow, msg.FromUsername, ansiReset, msg.Text)
+	})
+	if err != nil {
+		log.Fatalf("dm setup failed: %v", err)
 	}
-	sub, err := t.Subscribe()
+	announceDM(ctx, h, dmManager)
+
+	ps, err := pubsub.NewGossipSub(ctx, h)
 	if err != nil {
 		log.Fatal(err)
 	}
+	bootstrapPeers, err := parseBootstrapInfos(*bootstrapCSV)
+	if err != nil {
+		log.Fatalf("parse --bootstrap: %v", err)
+	}
+	topic := &topicHandle{}
+	go runResilientPubSub(ctx, ps, h, topic, book, bootstrapPeers)
 
 	fmt.Printf("You are known as: %s%s%s\n", ansiCyan, username, ansiReset)
 
+	// Only stand up the DHT when something actually needs it, since
+	// bootstrapping it talks to the public IPFS network by default.
+	var routingDiscovery *drouting.RoutingDiscovery
+	if needsDHT {
+		kadDHT, err := setupDHT(ctx, h, bootstrapPeers)
+		if err != nil {
+			log.Fatalf("dht setup failed: %v", err)
+		}
+		defer kadDHT.Close()
+		relayDHT.set(kadDHT)
+		routingDiscovery = drouting.NewRoutingDiscovery(kadDHT)
+	}
+
+	if *relayService && routingDiscovery != nil {
+		dutil.Advertise(ctx, routingDiscovery, relayNamespace)
+		fmt.Println("[Relay] Advertising as a relay under the DHT's /libp2p/relay namespace")
+	} else if !*relayService && *relaysCSV == "" {
+		fmt.Println("[AutoNAT] Checking reachability...")
+		switch detectReachability(h, 10*time.Second) {
+		case network.ReachabilityPrivate:
+			fmt.Println("[AutoNAT] Behind a NAT — AutoRelay will request a reservation from a discovered relay.")
+		case network.ReachabilityPublic:
+			fmt.Println("[AutoNAT] Publicly reachable — no relay reservation needed.")
+		default:
+			fmt.Println("[AutoNAT] Reachability undetermined; AutoRelay will fall back to relay discovery if needed.")
+		}
+	}
+
 	if hostMode {
 		fmt.Println("\nStarting BlackBox host...")
 		fmt.Println("Your connection address(es):")
@@ -224,33 +914,28 @@ func main() {
 		// Show immediate addrs (loopback/LAN/etc)
 		printHostInfo(h)
 
-		// If AutoRelay is active, print the relay addr when available
-		if *relaysCSV != "" {
+		// If AutoRelay is active (static or DHT-discovered), print the relay
+		// addr when available.
+		if !*relayService {
 			waitForRelayAndPrint(h, 20*time.Second)
 		} else {
-			fmt.Println("(Tip) To get a relay address (no port-forward), restart with:")
-			fmt.Println("      --relays <relay-multiaddr[,relay2,...]>")
+			fmt.Println("(This node is a relay and does not need a reservation of its own.)")
 		}
 
-	} else if joinAddr != "" {
-		fmt.Println("\nAttempting to join BlackBox chat...")
-		maddr, err := multiaddr.NewMultiaddr(joinAddr)
-		if err != nil {
-			log.Fatalf("invalid multiaddr: %v\n", err)
+		if lobbyName != "" {
+			dutil.Advertise(ctx, routingDiscovery, rendezvousString(lobbyName))
+			fmt.Printf("[DHT] Advertising lobby %q — others can join with \"Join by lobby name\".\n", lobbyName)
 		}
-		info, err := peer.AddrInfoFromP2pAddr(maddr)
-		if err != nil {
-			log.Fatalf("failed to parse peer AddrInfo: %v\n", err)
-		}
-		if info.ID == h.ID() {
-			fmt.Println("[Info] That address points to your own node — already hosting.")
-			return
-		}
-		if !addrInfoIsReachable(info, 1500*time.Millisecond) {
-			fmt.Println("\n[Notice] Host may be behind firewall — still attempting...")
+
+		if *bridgeURL != "" && lobbyName != "" {
+			fmt.Println("[Bridge] NAT hole punching enabled via --bridge.")
+			fmt.Printf("→ Direct join string: %s%s@%s%s\n", ansiCyan, lobbyName, h.ID().String(), ansiReset)
 		}
-		if err := connectToPeer(ctx, h, joinAddr); err != nil {
-			log.Fatalf("connect failed: %v\n", err)
+
+	} else if lobbyMode {
+		fmt.Println("\nAttempting to join BlackBox lobby via DHT...")
+		if err := joinLobby(ctx, h, routingDiscovery, lobbyName); err != nil {
+			log.Fatalf("join lobby failed: %v\n", err)
 		}
 
 		if runtime.GOOS != "windows" {
@@ -266,33 +951,64 @@ func main() {
 		fmt.Printf("%sWelcome to BlackBox Chat!%s\n", ansiGreen, ansiReset)
 		fmt.Printf("%sNode:%s Client\n", ansiCyan, ansiReset)
 		fmt.Printf("%sYour ID:%s %s\n", ansiCyan, ansiReset, h.ID().String())
-		fmt.Printf("%sConnected to:%s %s\n", ansiCyan, ansiReset, joinAddr)
+		fmt.Printf("%sLobby:%s %s\n", ansiCyan, ansiReset, lobbyName)
 		fmt.Println("──────────────────────────────────────────")
 		fmt.Printf("%sType /help to see available commands%s\n", ansiDim, ansiReset)
 		fmt.Println("──────────────────────────────────────────")
-	}
 
-	// Message listener
-	go func() {
-		for {
-			msg, err := sub.Next(ctx)
+	} else if joinAddr != "" {
+		fmt.Println("\nAttempting to join BlackBox chat...")
+
+		if lobbyID, peerID, ok := parseHolePunchJoinAddr(joinAddr); ok {
+			if *bridgeURL == "" {
+				log.Fatalf("connection address %q is a hole-punch pair but --bridge wasn't set\n", joinAddr)
+			}
+			if err := connectViaHolePunch(ctx, h, lobbyID, peerID); err != nil {
+				log.Fatalf("hole punch failed, and no relay fallback configured: %v\n", err)
+			}
+		} else {
+			maddr, err := multiaddr.NewMultiaddr(joinAddr)
+			if err != nil {
+				log.Fatalf("invalid multiaddr: %v\n", err)
+			}
+			info, err := peer.AddrInfoFromP2pAddr(maddr)
 			if err != nil {
+				log.Fatalf("failed to parse peer AddrInfo: %v\n", err)
+			}
+			if info.ID == h.ID() {
+				fmt.Println("[Info] That address points to your own node — already hosting.")
 				return
 			}
-			if msg.ReceivedFrom == h.ID() {
-				continue
+			if !addrInfoIsReachable(info, 1500*time.Millisecond) {
+				fmt.Println("\n[Notice] Host may be behind firewall — still attempting...")
 			}
-			msgText := string(msg.Data)
-			parts := strings.SplitN(msgText, "]:", 2)
-			if len(parts) == 2 && strings.HasPrefix(parts[0], "[") {
-				username := strings.TrimPrefix(parts[0], "[")
-				message := parts[1]
-				fmt.Printf("\n%s%s%s: %s\n> ", ansiYellow, username, ansiReset, message)
-			} else {
-				fmt.Printf("\n%s\n> ", msgText)
+			if err := connectToPeer(ctx, h, joinAddr); err != nil {
+				log.Fatalf("connect failed: %v\n", err)
 			}
 		}
-	}()
+
+		if runtime.GOOS != "windows" {
+			fmt.Print("\033[H\033[2J")
+			logoPath := filepath.Join("assets", "blackboxlogo.sh")
+			cmd := exec.Command("bash", logoPath)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			_ = cmd.Run()
+		}
+
+		fmt.Println("\n──────────────────────────────────────────")
+		fmt.Printf("%sWelcome to BlackBox Chat!%s\n", ansiGreen, ansiReset)
+		fmt.Printf("%sNode:%s Client\n", ansiCyan, ansiReset)
+		fmt.Printf("%sYour ID:%s %s\n", ansiCyan, ansiReset, h.ID().String())
+		fmt.Printf("%sConnected to:%s %s\n", ansiCyan, ansiReset, joinAddr)
+		fmt.Println("──────────────────────────────────────────")
+		fmt.Printf("%sType /help to see available commands%s\n", ansiDim, ansiReset)
+		fmt.Println("──────────────────────────────────────────")
+	}
+
+	// Message receiving happens in runResilientPubSub, started earlier; it
+	// rejoins/resubscribes on its own after drops, so there's nothing to do
+	// here but read the prompt.
 
 	scanner := bufio.NewScanner(os.Stdin)
 	fmt.Print("> ")
@@ -308,14 +1024,23 @@ func main() {
 			printHelp()
 			fmt.Print("> ")
 			continue
+		case line == "/peers" || strings.HasPrefix(line, "/addpeer ") || strings.HasPrefix(line, "/removepeer "):
+			handlePeerCommand(ctx, h, book, line)
+			fmt.Print("> ")
+			continue
+		case strings.HasPrefix(line, "/msg "):
+			handleDMCommand(ctx, dmManager, line)
+			fmt.Print("> ")
+			continue
 		case line == "/exit":
 			fmt.Println("\n[BlackBox] Disconnecting...")
+			_ = book.Save()
 			cancel()
 			os.Exit(0)
 		}
 
 		message := fmt.Sprintf("[%s]:%s", username, line)
-		if err := t.Publish(ctx, []byte(message)); err != nil {
+		if err := topic.Publish(ctx, []byte(message)); err != nil {
 			fmt.Println("publish error:", err)
 		}
 		fmt.Printf("%s%s%s: %s\n> ", ansiBlue, username, ansiReset, line)
@@ -339,23 +1064,96 @@ Quick Start:
   2. To join a chat:
      ./blackbox --join <connection-address> --username YourName
 
+  3. To join a chat by lobby name (no multiaddr needed):
+     ./blackbox
+     (then pick "Join by lobby name" from the menu and enter the lobby name and your username when prompted)
+
 Usage:
-  ./blackbox [--host] [--join <multiaddr>] [--username <n>] [--port <n>] [--relays <maddr[,maddr...] ] 
+  ./blackbox [--host] [--join <multiaddr>] [--username <n>] [--port <n>] [--relays <maddr[,maddr...] ] [--bootstrap <maddr[,maddr...] ] [--relay-service] [--bridge <url>]
 
 Options:
-  --host               Start a new BlackBox node and host a lobby
-  --join <multiaddr>   Join an existing peer by address
-  --username <name>    Set your chat username
-  --port <n>           Listen port (default 4001)
-  --relays <list>      Comma-separated relay multiaddrs to enable AutoRelay
-  --help               Show this help message
+  --host                      Start a new BlackBox node and host a lobby
+  --join <multiaddr>          Join an existing peer by address
+  --username <name>           Set your chat username
+  --port <n>                  Listen port (default 4001)
+  --relays <list>             Comma-separated relay multiaddrs to enable AutoRelay
+  --bootstrap <list>          Comma-separated DHT bootstrap multiaddrs (defaults to public IPFS nodes)
+  --relay-service             Run this node as a circuit-v2 relay for other BlackBox users
+  --relay-max-reservations <n>     Max peers that may hold a reservation on this relay at once (relay-service mode, default 128)
+  --relay-max-circuits-per-peer <n> Max concurrent relayed connections per peer (relay-service mode, default 16)
+  --relay-conn-data-limit-mb <n>   Reset a relayed connection after it forwards this much data, in MB (relay-service mode, default 128)
+  --relay-reservation-ttl <d> How long a relay reservation stays valid (relay-service mode, default 1h)
+  --bridge <url>              Rendezvous/bridge server URL for direct NAT hole punching
+  --holepunch-ports <list>    Comma-separated local candidate ports to offer when hole punching
+  --help                      Show this help message
+
+Chat commands:
+  /peers                 List peers in your address book (~/.blackbox/addrbook.json)
+  /addpeer <multiaddr>   Add a peer as persistent and connect to it now
+  /removepeer <peerID>   Remove a peer from the address book
+  /msg <peer|username> <message>   Send an end-to-end encrypted direct message
 
 Notes:
  - When joining, the CLI validates the multiaddr and checks reachability before attempting a libp2p connect.
- - To avoid manual port-forwarding, host with --relays and share the printed /p2p-circuit address.
+ - Without --relays, AutoNAT detects whether you're behind a NAT and AutoRelay discovers a relay via the DHT automatically — no --relays needed.
+ - To run your own community relay instead of relying on public infrastructure, start a node with --relay-service.
+ - "Join by lobby name" uses a Kademlia DHT to find peers advertising under a shared lobby name, so no multiaddr needs to be copy/pasted.
+ - Every peer you connect to is recorded in the address book; persistent peers (added via /addpeer) are automatically reconnected with backoff from the next start on.
+ - With --bridge set, a host can share a "<lobbyID>@<peerID>" string instead of a relay address; joiners hole-punch a direct connection through the bridge. There is no relay fallback: if every candidate pair fails, the join exits with an error.
+ - The lobby topic survives Wi-Fi flaps and relay reconnects: a dropped subscription is automatically rejoined with capped exponential backoff.
+ - /msg is end-to-end encrypted (X25519 ECDH over your existing node identity + XChaCha20-Poly1305) and bypasses the lobby topic entirely, so only the two of you can read it. That key is static per peer pair, not per session, so it has no forward secrecy. You can /msg someone by name only after their hello (sent when you connect) or a DM from them has reached you; otherwise use their peer ID. Usernames are unauthenticated and the latest claim wins: nothing stops another peer from later claiming a name you already associate with someone else, so prefer /msg by peer ID when it matters.
 `)
 }
 
+// parsePorts parses a comma-separated list of TCP ports, used for the
+// candidate source ports offered during NAT hole punching.
+func parsePorts(csv string) ([]int, error) {
+	parts := strings.Split(csv, ",")
+	ports := make([]int, 0, len(parts))
+	for _, p := range parts {
+		s := strings.TrimSpace(p)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad port %q: %w", s, err)
+		}
+		ports = append(ports, n)
+	}
+	return ports, nil
+}
+
+// parseHolePunchJoinAddr recognizes the "<lobbyID>@<peerID>" connection
+// address format: the lobby ID to hole-punch under, and the host's peer ID
+// (shown in its own "Node ID:" line) to verify against once connected.
+func parseHolePunchJoinAddr(joinAddr string) (lobbyID string, peerID peer.ID, ok bool) {
+	lobbyID, idStr, found := strings.Cut(joinAddr, "@")
+	if !found || lobbyID == "" || idStr == "" {
+		return "", "", false
+	}
+	pid, err := peer.Decode(idStr)
+	if err != nil {
+		return "", "", false
+	}
+	return lobbyID, pid, true
+}
+
+// connectViaHolePunch dials peerID through the holepunch Transport, which
+// hole-punches via the bridge under lobbyID instead of connecting directly.
+func connectViaHolePunch(ctx context.Context, h host.Host, lobbyID string, peerID peer.ID) error {
+	hpAddr, err := holepunch.Addr(lobbyID)
+	if err != nil {
+		return fmt.Errorf("build holepunch address: %w", err)
+	}
+	info := peer.AddrInfo{ID: peerID, Addrs: []multiaddr.Multiaddr{hpAddr}}
+	if err := h.Connect(ctx, info); err != nil {
+		return fmt.Errorf("hole punch connect failed: %w", err)
+	}
+	fmt.Println("\n✓ Connected via NAT hole punching!")
+	return nil
+}
+
 // connectToPeer tries to perform a libp2p connect and returns an error if it fails.
 func connectToPeer(ctx context.Context, h host.Host, addr string) error {
 	maddr, err := multiaddr.NewMultiaddr(addr)